@@ -25,4 +25,5 @@ var (
 	errNonSTUNMessage                = errors.New("non-STUN message from STUN server")
 	errFailedToDecodeSTUN            = errors.New("failed to decode STUN message")
 	errUnexpectedSTUNRequestMessage  = errors.New("unexpected STUN request message")
+	errFailedToCastUDPAddr           = errors.New("turn: failed to cast net.Addr to *net.UDPAddr")
 )
@@ -0,0 +1,147 @@
+// +build !js
+
+package turn_test
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"time"
+
+	"github.com/pion/turn/v2"
+)
+
+func ExampleGenerateAuthKey() {
+	// The key is computed once per user and handed out by the AuthHandler,
+	// so the server never needs to keep plaintext passwords around.
+	key := turn.GenerateAuthKey("user", "pion.ly", "pass")
+	fmt.Printf("%x\n", key)
+	// Output: df52e50a53a959db6de78bae8854ae8c
+}
+
+func ExampleNewLongTermAuthHandler() {
+	// Time windowed credentials are generated by a separate service that
+	// shares a secret with the TURN server.
+	username, _, err := turn.GenerateLongTermCredentials("HELLO_WORLD", time.Minute)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	authHandler := turn.NewLongTermAuthHandler("HELLO_WORLD", nil)
+	_, ok := authHandler(username, "pion.ly", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 5000})
+	fmt.Println(ok)
+	// Output: true
+}
+
+func ExampleNewServer() {
+	udpListener, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		log.Panic(err)
+	}
+
+	usersMap := map[string][]byte{
+		"user": turn.GenerateAuthKey("user", "pion.ly", "pass"),
+	}
+
+	server, err := turn.NewServer(turn.ServerConfig{
+		Realm: "pion.ly",
+		AuthHandler: func(username, realm string, srcAddr net.Addr) (key []byte, ok bool) {
+			key, ok = usersMap[username]
+			return key, ok
+		},
+		PacketConnConfigs: []turn.PacketConnConfig{
+			{
+				PacketConn: udpListener,
+				RelayAddressGenerator: &turn.RelayAddressGeneratorStatic{
+					RelayAddress: net.ParseIP("127.0.0.1"),
+					Address:      "127.0.0.1",
+				},
+			},
+		},
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if err = server.Close(); err != nil {
+		log.Panic(err)
+	}
+	fmt.Println("server closed")
+	// Output: server closed
+}
+
+func ExampleClient_Allocate() {
+	udpListener, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		log.Panic(err)
+	}
+
+	server, err := turn.NewServer(turn.ServerConfig{
+		Realm: "pion.ly",
+		AuthHandler: func(username, realm string, srcAddr net.Addr) (key []byte, ok bool) {
+			return turn.GenerateAuthKey("user", "pion.ly", "pass"), username == "user"
+		},
+		PacketConnConfigs: []turn.PacketConnConfig{
+			{
+				PacketConn: udpListener,
+				RelayAddressGenerator: &turn.RelayAddressGeneratorStatic{
+					RelayAddress: net.ParseIP("127.0.0.1"),
+					Address:      "127.0.0.1",
+				},
+			},
+		},
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+	defer server.Close() //nolint:errcheck
+
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		log.Panic(err)
+	}
+	defer conn.Close() //nolint:errcheck
+
+	client, err := turn.NewClient(&turn.ClientConfig{
+		TURNServerAddr: udpListener.LocalAddr().String(),
+		Conn:           conn,
+		Username:       "user",
+		Password:       "pass",
+		Realm:          "pion.ly",
+	})
+	if err != nil {
+		log.Panic(err)
+	}
+	defer client.Close()
+
+	if err = client.Listen(); err != nil {
+		log.Panic(err)
+	}
+
+	// Allocate returns the relayed transport address as a net.PacketConn
+	relayConn, err := client.Allocate()
+	if err != nil {
+		log.Panic(err)
+	}
+	defer relayConn.Close() //nolint:errcheck
+
+	peer, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		log.Panic(err)
+	}
+	defer peer.Close() //nolint:errcheck
+
+	// The first write to a peer installs a permission on the server, later
+	// writes are bound to a channel.
+	if _, err = relayConn.WriteTo([]byte("Hello"), peer.LocalAddr()); err != nil {
+		log.Panic(err)
+	}
+
+	buf := make([]byte, 1500)
+	n, from, err := peer.ReadFrom(buf)
+	if err != nil {
+		log.Panic(err)
+	}
+	fmt.Printf("%s from relay: %v\n", buf[:n], from.String() == relayConn.LocalAddr().String())
+	// Output: Hello from relay: true
+}
@@ -39,6 +39,18 @@ func addr2IPFingerprint(addr net.Addr) string {
 	return "" // shoud never happen
 }
 
+// expiresIn returns the time left until the UnixNano deadline, rounded to the
+// nearest second. Unset or passed deadlines return 0.
+func expiresIn(expiresAt int64) time.Duration {
+	if expiresAt == 0 {
+		return 0
+	}
+	if d := time.Until(time.Unix(0, expiresAt)).Round(time.Second); d > 0 {
+		return d
+	}
+	return 0
+}
+
 // NewAllocation creates a new instance of NewAllocation.
 func NewAllocation(turnSocket net.PacketConn, fiveTuple *FiveTuple, log logging.LeveledLogger) *Allocation {
 	return &Allocation{
@@ -62,20 +74,24 @@ func (a *Allocation) GetPermission(addr net.Addr) *Permission {
 func (a *Allocation) AddPermission(p *Permission) {
 	fingerprint := addr2IPFingerprint(p.Addr)
 
-	a.permissionsLock.RLock()
-	existedPermission, ok := a.permissions[fingerprint]
-	a.permissionsLock.RUnlock()
+	a.permissionsLock.Lock()
+	defer a.permissionsLock.Unlock()
+
+	// Close stops the permission timers under permissionsLock, don't start
+	// new ones once it has run.
+	select {
+	case <-a.closed:
+		return
+	default:
+	}
 
-	if ok {
+	if existedPermission, ok := a.permissions[fingerprint]; ok {
 		existedPermission.refresh(permissionTimeout)
 		return
 	}
 
 	p.allocation = a
-	a.permissionsLock.Lock()
 	a.permissions[fingerprint] = p
-	a.permissionsLock.Unlock()
-
 	p.start(permissionTimeout)
 }
 
@@ -166,20 +182,23 @@ func (a *Allocation) Refresh(lifetime time.Duration) {
 
 // Close closes the allocation
 func (a *Allocation) Close() error {
+	// closed is only closed while holding permissionsLock so concurrent
+	// calls to Close and AddPermission are serialized against it.
+	a.permissionsLock.Lock()
 	select {
 	case <-a.closed:
+		a.permissionsLock.Unlock()
 		return nil
 	default:
 	}
 	close(a.closed)
 
-	a.lifetimeTimer.Stop()
-
-	a.permissionsLock.RLock()
 	for _, p := range a.permissions {
 		p.lifetimeTimer.Stop()
 	}
-	a.permissionsLock.RUnlock()
+	a.permissionsLock.Unlock()
+
+	a.lifetimeTimer.Stop()
 
 	a.channelBindingsLock.RLock()
 	for _, c := range a.channelBindings {
@@ -238,7 +257,12 @@ func (a *Allocation) packetHandler(m *Manager) {
 				a.log.Errorf("Failed to send ChannelData from allocation %v %v", srcAddr, err)
 			}
 		} else if p := a.GetPermission(srcAddr); p != nil {
-			udpAddr := srcAddr.(*net.UDPAddr)
+			udpAddr, ok := srcAddr.(*net.UDPAddr)
+			if !ok {
+				a.log.Errorf("Failed to send DataIndication from allocation %v %v", srcAddr, errFailedToCastUDPAddr)
+				continue
+			}
+
 			peerAddressAttr := proto.PeerAddress{IP: udpAddr.IP, Port: udpAddr.Port}
 			dataAttr := proto.Data(buffer[:n])
 
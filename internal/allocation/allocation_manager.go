@@ -100,16 +100,25 @@ func (m *Manager) CreateAllocation(fiveTuple *FiveTuple, turnSocket net.PacketCo
 	a.RelaySocket = conn
 	a.RelayAddr = relayAddr
 
-	m.log.Debugf("listening on relay addr: %s", a.RelayAddr.String())
+	// Another request for the same FiveTuple may have raced us while the
+	// relay socket was being allocated, check again under the write lock.
+	m.lock.Lock()
+	if _, ok := m.allocations[fiveTuple.Fingerprint()]; ok {
+		m.lock.Unlock()
+		if err := conn.Close(); err != nil {
+			m.log.Errorf("Failed to close relay socket: %v", err)
+		}
+		return nil, fmt.Errorf("%w: %v", errDupeFiveTuple, fiveTuple)
+	}
 
 	a.lifetimeTimer = time.AfterFunc(lifetime, func() {
 		m.DeleteAllocation(a.fiveTuple)
 	})
-
-	m.lock.Lock()
 	m.allocations[fiveTuple.Fingerprint()] = a
 	m.lock.Unlock()
 
+	m.log.Debugf("listening on relay addr: %s", a.RelayAddr.String())
+
 	go a.packetHandler(m)
 	return a, nil
 }
@@ -135,14 +144,7 @@ func (m *Manager) DeleteAllocation(fiveTuple *FiveTuple) {
 // CreateReservation stores the reservation for the token+port
 func (m *Manager) CreateReservation(reservationToken string, port int) {
 	time.AfterFunc(30*time.Second, func() {
-		m.lock.Lock()
-		defer m.lock.Unlock()
-		for i := len(m.reservations) - 1; i >= 0; i-- {
-			if m.reservations[i].token == reservationToken {
-				m.reservations = append(m.reservations[:i], m.reservations[i+1:]...)
-				return
-			}
-		}
+		m.DeleteReservation(reservationToken)
 	})
 
 	m.lock.Lock()
@@ -166,6 +168,19 @@ func (m *Manager) GetReservation(reservationToken string) (int, bool) {
 	return 0, false
 }
 
+// DeleteReservation removes the reservation for the token if it exists
+func (m *Manager) DeleteReservation(reservationToken string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	for i := len(m.reservations) - 1; i >= 0; i-- {
+		if m.reservations[i].token == reservationToken {
+			m.reservations = append(m.reservations[:i], m.reservations[i+1:]...)
+			return
+		}
+	}
+}
+
 // GetRandomEvenPort returns a random un-allocated udp4 port
 func (m *Manager) GetRandomEvenPort() (int, error) {
 	conn, addr, err := m.allocatePacketConn("udp4", 0)
@@ -6,11 +6,16 @@ import (
 	"io"
 	"math/rand"
 	"net"
+	"runtime"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/pion/logging"
+	"github.com/pion/turn/v2/internal/ipnet"
 	"github.com/pion/turn/v2/internal/proto"
 	"github.com/stretchr/testify/assert"
 )
@@ -23,8 +28,12 @@ func TestManager(t *testing.T) {
 		{"CreateInvalidAllocation", subTestCreateInvalidAllocation},
 		{"CreateAllocation", subTestCreateAllocation},
 		{"CreateAllocationDuplicateFiveTuple", subTestCreateAllocationDuplicateFiveTuple},
+		{"CreateAllocationDuplicateFiveTupleConcurrent", subTestCreateAllocationDuplicateFiveTupleConcurrent},
 		{"DeleteAllocation", subTestDeleteAllocation},
+		{"GetChannelBind", subTestGetChannelBind},
+		{"GetAllocationMultiple", subTestGetAllocationMultiple},
 		{"AllocationTimeout", subTestAllocationTimeout},
+		{"AllocationTimeoutUnderTraffic", subTestAllocationTimeoutUnderTraffic},
 		{"Close", subTestManagerClose},
 	}
 
@@ -88,6 +97,33 @@ func subTestCreateAllocationDuplicateFiveTuple(t *testing.T, turnSocket net.Pack
 	}
 }
 
+// test that concurrent requests for the same FiveTuple only create one allocation
+func subTestCreateAllocationDuplicateFiveTupleConcurrent(t *testing.T, turnSocket net.PacketConn) {
+	m, err := newTestManager()
+	assert.NoError(t, err)
+
+	fiveTuple := randomFiveTuple()
+
+	var wg sync.WaitGroup
+	var created int32
+	start := make(chan struct{})
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			if a, err := m.CreateAllocation(fiveTuple, turnSocket, 0, proto.DefaultLifetime); err == nil && a != nil {
+				atomic.AddInt32(&created, 1)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&created), "only one allocation should be created per FiveTuple")
+	assert.NoError(t, m.Close())
+}
+
 func subTestDeleteAllocation(t *testing.T, turnSocket net.PacketConn) {
 	m, err := newTestManager()
 	assert.NoError(t, err)
@@ -107,6 +143,92 @@ func subTestDeleteAllocation(t *testing.T, turnSocket net.PacketConn) {
 	}
 }
 
+// test that each FiveTuple resolves to its own allocation
+func subTestGetAllocationMultiple(t *testing.T, turnSocket net.PacketConn) {
+	m, err := newTestManager()
+	assert.NoError(t, err)
+
+	fiveTuples := make([]*FiveTuple, 10)
+	relayAddrs := make([]net.Addr, 10)
+	for i := range fiveTuples {
+		fiveTuples[i] = &FiveTuple{
+			SrcAddr: &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 5000 + i},
+			DstAddr: turnSocket.LocalAddr(),
+		}
+
+		a, err := m.CreateAllocation(fiveTuples[i], turnSocket, 0, proto.DefaultLifetime)
+		assert.NoError(t, err)
+		relayAddrs[i] = a.RelayAddr
+	}
+
+	for i, fiveTuple := range fiveTuples {
+		a := m.GetAllocation(fiveTuple)
+		if assert.NotNil(t, a) {
+			assert.True(t, a.fiveTuple.Equal(fiveTuple))
+			assert.Equal(t, relayAddrs[i], a.RelayAddr)
+		}
+	}
+
+	assert.Nil(t, m.GetAllocation(&FiveTuple{
+		SrcAddr: &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 5010},
+		DstAddr: turnSocket.LocalAddr(),
+	}), "FiveTuple without an allocation shouldn't match")
+
+	assert.NoError(t, m.Close())
+}
+
+// test that channel lookups are scoped to the allocation of the FiveTuple
+func subTestGetChannelBind(t *testing.T, turnSocket net.PacketConn) {
+	m, err := newTestManager()
+	assert.NoError(t, err)
+
+	fiveTuple1 := randomFiveTuple()
+	a1, err := m.CreateAllocation(fiveTuple1, turnSocket, 0, proto.DefaultLifetime)
+	assert.NoError(t, err)
+
+	fiveTuple2 := randomFiveTuple()
+	a2, err := m.CreateAllocation(fiveTuple2, turnSocket, 0, proto.DefaultLifetime)
+	assert.NoError(t, err)
+
+	peer1 := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 5000}
+	peer2 := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 5001}
+
+	// channel exists on the allocation it was bound on
+	assert.NoError(t, a1.AddChannelBind(NewChannelBind(proto.MinChannelNumber, peer1, m.log), proto.DefaultLifetime))
+	c := m.GetAllocation(fiveTuple1).GetChannelByNumber(proto.MinChannelNumber)
+	if assert.NotNil(t, c) {
+		assert.Equal(t, peer1, c.Peer)
+	}
+
+	// the same channel number bound on another allocation doesn't collide
+	assert.NoError(t, a2.AddChannelBind(NewChannelBind(proto.MinChannelNumber, peer2, m.log), proto.DefaultLifetime))
+	c = m.GetAllocation(fiveTuple2).GetChannelByNumber(proto.MinChannelNumber)
+	if assert.NotNil(t, c) {
+		assert.Equal(t, peer2, c.Peer)
+	}
+	c = m.GetAllocation(fiveTuple1).GetChannelByNumber(proto.MinChannelNumber)
+	if assert.NotNil(t, c) {
+		assert.Equal(t, peer1, c.Peer)
+	}
+
+	// a FiveTuple with a different source port doesn't find the channel
+	srcAddr := fiveTuple1.SrcAddr.(*net.UDPAddr)
+	assert.Nil(t, m.GetAllocation(&FiveTuple{
+		SrcAddr: &net.UDPAddr{IP: srcAddr.IP, Port: srcAddr.Port + 1},
+		DstAddr: fiveTuple1.DstAddr,
+	}))
+	assert.Nil(t, a1.GetChannelByAddr(peer2), "peer with a different port shouldn't match")
+
+	// expired channel binds are not returned
+	assert.NoError(t, a1.AddChannelBind(NewChannelBind(proto.MinChannelNumber+1, peer2, m.log), 100*time.Millisecond))
+	assert.NotNil(t, a1.GetChannelByNumber(proto.MinChannelNumber+1))
+	time.Sleep(200 * time.Millisecond)
+	assert.Nil(t, a1.GetChannelByNumber(proto.MinChannelNumber+1))
+	assert.Nil(t, a1.GetChannelByAddr(peer2))
+
+	assert.NoError(t, m.Close())
+}
+
 // test that allocation should be closed if timeout
 func subTestAllocationTimeout(t *testing.T, turnSocket net.PacketConn) {
 	m, err := newTestManager()
@@ -135,6 +257,71 @@ func subTestAllocationTimeout(t *testing.T, turnSocket net.PacketConn) {
 	}
 }
 
+// lifetime expiry must stop packetHandler even while it is busy relaying
+func subTestAllocationTimeoutUnderTraffic(t *testing.T, turnSocket net.PacketConn) {
+	m, err := newTestManager()
+	assert.NoError(t, err)
+
+	clientListener, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+
+	peer, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+
+	baseline := runtime.NumGoroutine()
+
+	a, err := m.CreateAllocation(&FiveTuple{
+		SrcAddr: clientListener.LocalAddr(),
+		DstAddr: turnSocket.LocalAddr(),
+	}, turnSocket, 0, time.Second)
+	assert.NoError(t, err)
+	a.AddPermission(NewPermission(peer.LocalAddr(), m.log))
+
+	relayAddr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: a.RelayAddr.(*net.UDPAddr).Port}
+	done := make(chan struct{})
+	sendDone := make(chan struct{})
+	go func() {
+		defer close(sendDone)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if _, err2 := peer.WriteTo([]byte("Hello"), relayAddr); err2 != nil {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	select {
+	case <-a.closed:
+	case <-time.After(2 * time.Second):
+		t.Error("allocation should be closed once its lifetime expires")
+	}
+	close(done)
+	<-sendDone
+
+	assert.True(t, isClose(a.RelaySocket), "relay socket should be closed once the lifetime expires")
+	assert.Nil(t, m.GetAllocation(a.fiveTuple), "allocation should be deleted once the lifetime expires")
+
+	// packetHandler and the timer callbacks exit asynchronously
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > baseline && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.LessOrEqual(t, runtime.NumGoroutine(), baseline, "goroutines started by the allocation should exit")
+
+	assert.NoError(t, m.Close())
+	assert.NoError(t, clientListener.Close())
+	assert.NoError(t, peer.Close())
+}
+
 // test for manager close
 func subTestManagerClose(t *testing.T, turnSocket net.PacketConn) {
 	m, err := newTestManager()
@@ -161,6 +348,109 @@ func subTestManagerClose(t *testing.T, turnSocket net.PacketConn) {
 	}
 }
 
+// test relaying for a 100 participant conference, each participant having
+// permissions for every other participant and a channel bound to its peer
+func TestConferenceScale(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping conference scale test in short mode")
+	}
+
+	const (
+		participants   = 100
+		packets        = 50
+		packetInterval = 20 * time.Millisecond // 50 packets per second per participant
+	)
+
+	m, err := newTestManager()
+	assert.NoError(t, err)
+
+	turnSocket, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	var (
+		wg        sync.WaitGroup
+		latencies []time.Duration
+		lock      sync.Mutex
+		closers   []io.Closer
+	)
+
+	for i := 0; i < participants; i++ {
+		client, err := net.ListenPacket("udp4", "127.0.0.1:0")
+		assert.NoError(t, err)
+		peer, err := net.ListenPacket("udp4", "127.0.0.1:0")
+		assert.NoError(t, err)
+		closers = append(closers, client, peer)
+
+		a, err := m.CreateAllocation(&FiveTuple{
+			SrcAddr: client.LocalAddr(),
+			DstAddr: turnSocket.LocalAddr(),
+		}, turnSocket, 0, proto.DefaultLifetime)
+		assert.NoError(t, err)
+
+		// 98 permissions for remote participants and one for the local peer
+		for j := 0; j < participants-2; j++ {
+			a.AddPermission(NewPermission(&net.UDPAddr{IP: net.IPv4(10, 0, byte(i), byte(j)), Port: 5000}, m.log))
+		}
+		assert.NoError(t, a.AddChannelBind(NewChannelBind(proto.MinChannelNumber, peer.LocalAddr(), m.log), proto.DefaultLifetime))
+
+		_, port, err := ipnet.AddrIPPort(a.RelaySocket.LocalAddr())
+		assert.NoError(t, err)
+		relayAddr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}
+
+		wg.Add(1)
+		go func(i int, client, peer net.PacketConn) {
+			defer wg.Done()
+
+			// stagger participants across the packet interval like
+			// independent media senders would be
+			time.Sleep(packetInterval * time.Duration(i) / participants)
+			ticker := time.NewTicker(packetInterval)
+			defer ticker.Stop()
+
+			buffer := make([]byte, rtpMTU)
+			for k := 0; k < packets; k++ {
+				<-ticker.C
+				sent := time.Now()
+				if _, err := peer.WriteTo([]byte("conference"), relayAddr); err != nil {
+					t.Errorf("Failed to write to relay %v", err)
+					return
+				}
+
+				assert.NoError(t, client.SetReadDeadline(time.Now().Add(time.Second)))
+				n, _, err := client.ReadFrom(buffer)
+				if err != nil {
+					t.Errorf("Failed to read relayed packet %v", err)
+					return
+				}
+				elapsed := time.Since(sent)
+				assert.True(t, proto.IsChannelData(buffer[:n]), "should be channel data")
+
+				lock.Lock()
+				latencies = append(latencies, elapsed)
+				lock.Unlock()
+			}
+		}(i, client, peer)
+	}
+
+	wg.Wait()
+
+	assert.Len(t, latencies, participants*packets)
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	p99 := latencies[len(latencies)*99/100]
+	t.Logf("relay latency p50 %v p99 %v", latencies[len(latencies)/2], p99)
+	// With a single CPU the relay and all participants are serialized on
+	// it, so the latency measures the scheduler rather than the relay.
+	if runtime.NumCPU() > 1 {
+		assert.Less(t, int64(p99), int64(5*time.Millisecond), "p99 relay latency should be under 5ms")
+	}
+
+	assert.NoError(t, m.Close())
+	assert.NoError(t, turnSocket.Close())
+	for _, c := range closers {
+		assert.NoError(t, c.Close())
+	}
+}
+
 func randomFiveTuple() *FiveTuple {
 	/* #nosec */
 	return &FiveTuple{
@@ -6,9 +6,11 @@ import (
 	"fmt"
 	"net"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/pion/logging"
 	"github.com/pion/stun"
 	"github.com/pion/turn/v2/internal/ipnet"
 	"github.com/pion/turn/v2/internal/proto"
@@ -29,7 +31,10 @@ func TestAllocation(t *testing.T) {
 		{"RemoveChannelBind", subTestRemoveChannelBind},
 		{"Refresh", subTestAllocationRefresh},
 		{"Close", subTestAllocationClose},
+		{"CloseConcurrentAddPermission", subTestAllocationCloseConcurrentAddPermission},
 		{"packetHandler", subTestPacketHandler},
+		{"packetHandlerClosedRelaySocket", subTestPacketHandlerClosedRelaySocket},
+		{"packetHandlerNonUDPAddr", subTestPacketHandlerNonUDPAddr},
 	}
 
 	for _, tc := range tt {
@@ -214,6 +219,45 @@ func subTestAllocationClose(t *testing.T) {
 	assert.True(t, isClose(a.RelaySocket), "should be closed")
 }
 
+func subTestAllocationCloseConcurrentAddPermission(t *testing.T) {
+	l, err := net.ListenPacket("udp", "0.0.0.0:0")
+	if err != nil {
+		panic(err)
+	}
+
+	a := NewAllocation(nil, nil, nil)
+	a.RelaySocket = l
+	a.lifetimeTimer = time.AfterFunc(proto.DefaultLifetime, func() {})
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			a.AddPermission(NewPermission(&net.UDPAddr{IP: net.IPv4(10, 0, 0, byte(i)), Port: 5000}, nil))
+		}(i)
+	}
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			assert.NoError(t, a.Close())
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	// every permission still held by the allocation had its timer stopped
+	a.permissionsLock.RLock()
+	defer a.permissionsLock.RUnlock()
+	for _, p := range a.permissions {
+		assert.False(t, p.lifetimeTimer.Stop(), "permission timer should be stopped by Close")
+	}
+}
+
 func subTestPacketHandler(t *testing.T) {
 	network := "udp"
 
@@ -311,3 +355,187 @@ func subTestPacketHandler(t *testing.T) {
 	_ = peerListener1.Close()
 	_ = peerListener2.Close()
 }
+
+func subTestPacketHandlerClosedRelaySocket(t *testing.T) {
+	m, _ := newTestManager()
+
+	turnSocket, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+
+	fiveTuple := &FiveTuple{
+		SrcAddr: &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 5000},
+		DstAddr: turnSocket.LocalAddr(),
+	}
+	a, err := m.CreateAllocation(fiveTuple, turnSocket, 0, proto.DefaultLifetime)
+	assert.NoError(t, err)
+
+	// closing the relay socket makes packetHandler delete the allocation and exit
+	assert.NoError(t, a.RelaySocket.Close())
+
+	select {
+	case <-a.closed:
+	case <-time.After(100 * time.Millisecond):
+		t.Error("packetHandler should exit within 100ms of the relay socket closing")
+	}
+	assert.Nil(t, m.GetAllocation(fiveTuple), "allocation should be deleted once packetHandler exits")
+
+	_ = m.Close()
+	_ = turnSocket.Close()
+}
+
+// tcpAddrPacketConn reports every sender as a *net.TCPAddr and signals each time ReadFrom is entered
+type tcpAddrPacketConn struct {
+	net.PacketConn
+	readCh chan struct{}
+}
+
+func (c *tcpAddrPacketConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	c.readCh <- struct{}{}
+
+	n, addr, err := c.PacketConn.ReadFrom(p)
+	if err != nil {
+		return n, addr, err
+	}
+
+	udpAddr := addr.(*net.UDPAddr)
+	return n, &net.TCPAddr{IP: udpAddr.IP, Port: udpAddr.Port}, nil
+}
+
+func subTestPacketHandlerNonUDPAddr(t *testing.T) {
+	relaySocket, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+	mockRelaySocket := &tcpAddrPacketConn{PacketConn: relaySocket, readCh: make(chan struct{}, 2)}
+
+	m, err := NewManager(ManagerConfig{
+		LeveledLogger: logging.NewDefaultLoggerFactory().NewLogger("test"),
+		AllocatePacketConn: func(network string, requestedPort int) (net.PacketConn, net.Addr, error) {
+			return mockRelaySocket, relaySocket.LocalAddr(), nil
+		},
+		AllocateConn: func(network string, requestedPort int) (net.Conn, net.Addr, error) { return nil, nil, nil },
+	})
+	assert.NoError(t, err)
+
+	turnSocket, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+
+	peer, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+
+	fiveTuple := &FiveTuple{
+		SrcAddr: &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 5000},
+		DstAddr: turnSocket.LocalAddr(),
+	}
+	a, err := m.CreateAllocation(fiveTuple, turnSocket, 0, proto.DefaultLifetime)
+	assert.NoError(t, err)
+
+	// the permission matches the peer's IP whatever the address type, so the packet reaches the DataIndication path
+	a.AddPermission(NewPermission(&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)}, m.log))
+
+	<-mockRelaySocket.readCh
+	_, err = peer.WriteTo([]byte("Hello"), relaySocket.LocalAddr())
+	assert.NoError(t, err)
+
+	// packetHandler must drop the packet and go back to reading instead of panicking
+	select {
+	case <-mockRelaySocket.readCh:
+	case <-time.After(time.Second):
+		t.Fatal("packetHandler should keep reading after a packet from a non-UDP address")
+	}
+	assert.NotNil(t, m.GetAllocation(fiveTuple), "allocation should survive a packet from a non-UDP address")
+
+	assert.NoError(t, m.Close())
+	assert.NoError(t, turnSocket.Close())
+	assert.NoError(t, peer.Close())
+}
+
+func TestConcurrentPermissionExpiryAndRelay(t *testing.T) {
+	const permissionCount = 1000
+
+	m, err := newTestManager()
+	assert.NoError(t, err)
+
+	turnSocket, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+
+	clientListener, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+
+	var relayed int64
+	go func() {
+		buffer := make([]byte, rtpMTU)
+		for {
+			if _, _, err2 := clientListener.ReadFrom(buffer); err2 != nil {
+				return
+			}
+			atomic.AddInt64(&relayed, 1)
+		}
+	}()
+
+	a, err := m.CreateAllocation(&FiveTuple{
+		SrcAddr: clientListener.LocalAddr(),
+		DstAddr: turnSocket.LocalAddr(),
+	}, turnSocket, 0, proto.DefaultLifetime)
+	assert.NoError(t, err)
+
+	peer, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+
+	// one permission for the peer actually sending, the rest for unrelated IPs,
+	// all expiring together while the peer's packets are being relayed
+	addrs := []net.Addr{peer.LocalAddr()}
+	for i := 1; i < permissionCount; i++ {
+		addrs = append(addrs, &net.UDPAddr{IP: net.IPv4(10, 0, byte(i>>8), byte(i))})
+	}
+	for _, addr := range addrs {
+		p := NewPermission(addr, m.log)
+		a.AddPermission(p)
+		p.refresh(time.Second)
+	}
+
+	done := make(chan struct{})
+	sendDone := make(chan struct{})
+	go func() {
+		defer close(sendDone)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if _, err2 := peer.WriteTo([]byte("Hello"), a.RelayAddr); err2 != nil {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	time.Sleep(2 * time.Second)
+	close(done)
+	<-sendDone
+
+	a.permissionsLock.RLock()
+	remaining := len(a.permissions)
+	a.permissionsLock.RUnlock()
+	assert.Equal(t, 0, remaining, "all permissions should have expired")
+	assert.Nil(t, a.GetPermission(peer.LocalAddr()), "peer permission should have expired")
+	assert.NotZero(t, atomic.LoadInt64(&relayed), "packets should be relayed until the permission expires")
+
+	assert.NoError(t, m.Close())
+	assert.NoError(t, turnSocket.Close())
+	assert.NoError(t, clientListener.Close())
+	assert.NoError(t, peer.Close())
+}
@@ -1,7 +1,9 @@
 package allocation
 
 import (
+	"fmt"
 	"net"
+	"sync/atomic"
 	"time"
 
 	"github.com/pion/logging"
@@ -16,6 +18,7 @@ type ChannelBind struct {
 
 	allocation    *Allocation
 	lifetimeTimer *time.Timer
+	expiresAt     int64 // UnixNano, accessed atomically
 	log           logging.LeveledLogger
 }
 
@@ -28,16 +31,24 @@ func NewChannelBind(number proto.ChannelNumber, peer net.Addr, log logging.Level
 	}
 }
 
+// String returns a human readable representation of the ChannelBind
+func (c *ChannelBind) String() string {
+	return fmt.Sprintf("ChannelBind{channel=%#x peer=%v expires_in=%ds}",
+		uint16(c.Number), c.Peer, int64(expiresIn(atomic.LoadInt64(&c.expiresAt))/time.Second))
+}
+
 func (c *ChannelBind) start(lifetime time.Duration) {
+	atomic.StoreInt64(&c.expiresAt, time.Now().Add(lifetime).UnixNano())
 	c.lifetimeTimer = time.AfterFunc(lifetime, func() {
 		if !c.allocation.RemoveChannelBind(c.Number) {
-			c.log.Errorf("Failed to remove ChannelBind for %v %x %v", c.Number, c.Peer, c.allocation.fiveTuple)
+			c.log.Errorf("Failed to remove %v for %v", c, c.allocation.fiveTuple)
 		}
 	})
 }
 
 func (c *ChannelBind) refresh(lifetime time.Duration) {
+	atomic.StoreInt64(&c.expiresAt, time.Now().Add(lifetime).UnixNano())
 	if !c.lifetimeTimer.Reset(lifetime) {
-		c.log.Errorf("Failed to reset ChannelBind timer for %v %x %v", c.Number, c.Peer, c.allocation.fiveTuple)
+		c.log.Errorf("Failed to reset timer for %v %v", c, c.allocation.fiveTuple)
 	}
 }
@@ -2,10 +2,13 @@ package allocation
 
 import (
 	"net"
+	"regexp"
+	"strconv"
 	"testing"
 	"time"
 
 	"github.com/pion/turn/v2/internal/proto"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestChannelBind(t *testing.T) {
@@ -38,6 +41,27 @@ func TestChannelBindReset(t *testing.T) {
 	}
 }
 
+func TestChannelBindString(t *testing.T) {
+	a := NewAllocation(nil, nil, nil)
+
+	peer := &net.UDPAddr{IP: net.IPv4(1, 2, 3, 4), Port: 5678}
+	c := NewChannelBind(proto.MinChannelNumber, peer, nil)
+	assert.NoError(t, a.AddChannelBind(c, 540*time.Second))
+
+	match := regexp.MustCompile(`^ChannelBind\{channel=0x4000 peer=1\.2\.3\.4:5678 expires_in=(\d+)s\}$`).FindStringSubmatch(c.String())
+	if assert.Len(t, match, 2, c.String()) {
+		expiresIn, err := strconv.Atoi(match[1])
+		assert.NoError(t, err)
+		assert.InDelta(t, 540, expiresIn, 2)
+	}
+
+	c.refresh(60 * time.Second)
+	assert.Equal(t, "ChannelBind{channel=0x4000 peer=1.2.3.4:5678 expires_in=60s}", c.String())
+
+	c.lifetimeTimer.Stop()
+	a.GetPermission(peer).lifetimeTimer.Stop()
+}
+
 func newChannelBind(lifetime time.Duration) *ChannelBind {
 	a := NewAllocation(nil, nil, nil)
 
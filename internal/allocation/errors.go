@@ -14,4 +14,5 @@ var (
 	errLifetimeZero                = errors.New("allocations must not be created with a lifetime of 0")
 	errDupeFiveTuple               = errors.New("allocation attempt created with duplicate FiveTuple")
 	errFailedToCastUDPAddr         = errors.New("failed to cast net.Addr to *net.UDPAddr")
+	errUnknownProtocol             = errors.New("unknown protocol")
 )
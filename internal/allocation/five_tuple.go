@@ -3,6 +3,7 @@ package allocation
 import (
 	"fmt"
 	"net"
+	"strings"
 )
 
 // Protocol is an enum for relay protocol
@@ -14,6 +15,29 @@ const (
 	TCP
 )
 
+func (p Protocol) String() string {
+	switch p {
+	case UDP:
+		return "UDP"
+	case TCP:
+		return "TCP"
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(p))
+	}
+}
+
+// ParseProtocol parses a case insensitive protocol name
+func ParseProtocol(s string) (Protocol, error) {
+	switch strings.ToLower(s) {
+	case "udp":
+		return UDP, nil
+	case "tcp":
+		return TCP, nil
+	default:
+		return 0, fmt.Errorf("%w: %q", errUnknownProtocol, s)
+	}
+}
+
 // FiveTuple is the combination (client IP address and port, server IP
 // address and port, and transport protocol (currently one of UDP,
 // TCP, or TLS)) used to communicate between the client and the
@@ -25,6 +49,12 @@ type FiveTuple struct {
 	SrcAddr, DstAddr net.Addr
 }
 
+// String returns a human readable representation of the FiveTuple. It is
+// needed so the embedded Protocol's String isn't used for the whole FiveTuple.
+func (f *FiveTuple) String() string {
+	return fmt.Sprintf("%v %v->%v", f.Protocol, f.SrcAddr, f.DstAddr)
+}
+
 // Equal asserts if two FiveTuples are equal
 func (f *FiveTuple) Equal(b *FiveTuple) bool {
 	return f.Fingerprint() == b.Fingerprint()
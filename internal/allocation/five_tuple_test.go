@@ -1,6 +1,7 @@
 package allocation
 
 import (
+	"errors"
 	"net"
 	"testing"
 )
@@ -18,6 +19,58 @@ func TestFiveTupleProtocol(t *testing.T) {
 	}
 }
 
+func TestProtocolString(t *testing.T) {
+	tt := []struct {
+		protocol Protocol
+		expect   string
+	}{
+		{UDP, "UDP"},
+		{TCP, "TCP"},
+		{Protocol(7), "Unknown(7)"},
+	}
+
+	for _, tc := range tt {
+		if s := tc.protocol.String(); s != tc.expect {
+			t.Errorf("Expect %d to be %q, but %q", uint8(tc.protocol), tc.expect, s)
+		}
+	}
+}
+
+func TestParseProtocol(t *testing.T) {
+	tt := []struct {
+		in     string
+		expect Protocol
+		err    error
+	}{
+		{"udp", UDP, nil},
+		{"UDP", UDP, nil},
+		{"tcp", TCP, nil},
+		{"Tcp", TCP, nil},
+		{"tls", 0, errUnknownProtocol},
+		{"", 0, errUnknownProtocol},
+	}
+
+	for _, tc := range tt {
+		p, err := ParseProtocol(tc.in)
+		if !errors.Is(err, tc.err) {
+			t.Errorf("ParseProtocol(%q) expect error %v, but %v", tc.in, tc.err, err)
+		}
+		if p != tc.expect {
+			t.Errorf("ParseProtocol(%q) expect %v, but %v", tc.in, tc.expect, p)
+		}
+	}
+}
+
+func TestFiveTupleString(t *testing.T) {
+	srcAddr, _ := net.ResolveUDPAddr("udp", "1.2.3.4:3478")
+	dstAddr, _ := net.ResolveUDPAddr("udp", "5.6.7.8:3479")
+
+	f := &FiveTuple{UDP, srcAddr, dstAddr}
+	if s := f.String(); s != "UDP 1.2.3.4:3478->5.6.7.8:3479" {
+		t.Errorf("Unexpected FiveTuple string %q", s)
+	}
+}
+
 func TestFiveTupleEqual(t *testing.T) {
 	srcAddr1, _ := net.ResolveUDPAddr("udp", "0.0.0.0:3478")
 	srcAddr2, _ := net.ResolveUDPAddr("udp", "0.0.0.0:3479")
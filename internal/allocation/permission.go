@@ -1,7 +1,9 @@
 package allocation
 
 import (
+	"fmt"
 	"net"
+	"sync/atomic"
 	"time"
 
 	"github.com/pion/logging"
@@ -16,6 +18,7 @@ type Permission struct {
 	Addr          net.Addr
 	allocation    *Allocation
 	lifetimeTimer *time.Timer
+	expiresAt     int64 // UnixNano, accessed atomically
 	log           logging.LeveledLogger
 }
 
@@ -27,14 +30,22 @@ func NewPermission(addr net.Addr, log logging.LeveledLogger) *Permission {
 	}
 }
 
+// String returns a human readable representation of the Permission
+func (p *Permission) String() string {
+	return fmt.Sprintf("Permission{ip=%s expires_in=%ds}",
+		addr2IPFingerprint(p.Addr), int64(expiresIn(atomic.LoadInt64(&p.expiresAt))/time.Second))
+}
+
 func (p *Permission) start(lifetime time.Duration) {
+	atomic.StoreInt64(&p.expiresAt, time.Now().Add(lifetime).UnixNano())
 	p.lifetimeTimer = time.AfterFunc(lifetime, func() {
 		p.allocation.RemovePermission(p.Addr)
 	})
 }
 
 func (p *Permission) refresh(lifetime time.Duration) {
+	atomic.StoreInt64(&p.expiresAt, time.Now().Add(lifetime).UnixNano())
 	if !p.lifetimeTimer.Reset(lifetime) {
-		p.log.Errorf("Failed to reset permission timer for %v %v", p.Addr, p.allocation.fiveTuple)
+		p.log.Errorf("Failed to reset timer for %v %v", p, p.allocation.fiveTuple)
 	}
 }
@@ -0,0 +1,61 @@
+package allocation
+
+import (
+	"net"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPermissionString(t *testing.T) {
+	a := NewAllocation(nil, nil, nil)
+
+	p := NewPermission(&net.UDPAddr{IP: net.IPv4(1, 2, 3, 4), Port: 5678}, nil)
+	assert.Equal(t, "Permission{ip=1.2.3.4 expires_in=0s}", p.String(), "unstarted permission should not expire")
+
+	a.AddPermission(p)
+
+	match := regexp.MustCompile(`^Permission\{ip=1\.2\.3\.4 expires_in=(\d+)s\}$`).FindStringSubmatch(p.String())
+	if assert.Len(t, match, 2, p.String()) {
+		expiresIn, err := strconv.Atoi(match[1])
+		assert.NoError(t, err)
+		assert.InDelta(t, permissionTimeout.Seconds(), expiresIn, 2)
+	}
+
+	p.lifetimeTimer.Stop()
+}
+
+func TestPermissionStart(t *testing.T) {
+	a := NewAllocation(nil, nil, nil)
+
+	addr := &net.UDPAddr{IP: net.IPv4(1, 2, 3, 4), Port: 5678}
+	p := NewPermission(addr, nil)
+	p.allocation = a
+	a.permissions[addr2IPFingerprint(addr)] = p
+	p.start(50 * time.Millisecond)
+
+	assert.NotNil(t, a.GetPermission(addr))
+	time.Sleep(100 * time.Millisecond)
+	assert.Nil(t, a.GetPermission(addr), "permission should be removed after its lifetime")
+}
+
+func TestPermissionRefresh(t *testing.T) {
+	a := NewAllocation(nil, nil, nil)
+
+	addr := &net.UDPAddr{IP: net.IPv4(1, 2, 3, 4), Port: 5678}
+	p := NewPermission(addr, nil)
+	p.allocation = a
+	a.permissions[addr2IPFingerprint(addr)] = p
+	p.start(100 * time.Millisecond)
+
+	time.Sleep(60 * time.Millisecond)
+	p.refresh(100 * time.Millisecond)
+	time.Sleep(60 * time.Millisecond)
+	assert.NotNil(t, a.GetPermission(addr), "permission shouldn't expire after refresh")
+
+	time.Sleep(100 * time.Millisecond)
+	assert.Nil(t, a.GetPermission(addr), "permission should expire after the refreshed lifetime")
+}
@@ -134,6 +134,69 @@ func TestChannelData_Decode(t *testing.T) {
 	}
 }
 
+func TestChannelData_DecodePayload(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		buf    []byte
+		number ChannelNumber
+		data   []byte
+		err    error
+	}{
+		{
+			name:   "zero-length",
+			buf:    []byte{0x40, 0x00, 0x00, 0x00},
+			number: MinChannelNumber,
+			data:   []byte{},
+		},
+		{
+			name:   "exact",
+			buf:    []byte{0x40, 0x01, 0x00, 0x04, 1, 2, 3, 4},
+			number: MinChannelNumber + 1,
+			data:   []byte{1, 2, 3, 4},
+		},
+		{
+			name:   "padded",
+			buf:    []byte{0x40, 0x01, 0x00, 0x03, 1, 2, 3, 0},
+			number: MinChannelNumber + 1,
+			data:   []byte{1, 2, 3},
+		},
+		{
+			name:   "unpadded",
+			buf:    []byte{0x40, 0x01, 0x00, 0x03, 1, 2, 3},
+			number: MinChannelNumber + 1,
+			data:   []byte{1, 2, 3},
+		},
+		{
+			name: "truncated",
+			buf:  []byte{0x40, 0x01, 0x00, 0x05, 1, 2, 3, 4},
+			err:  ErrBadChannelDataLength,
+		},
+		{
+			name: "truncated header",
+			buf:  []byte{0x40, 0x01, 0x00},
+			err:  io.ErrUnexpectedEOF,
+		},
+	} {
+		m := &ChannelData{
+			Raw: tc.buf,
+		}
+		err := m.Decode()
+		if !errors.Is(err, tc.err) {
+			t.Errorf("unexpected: (%s) %v != %v", tc.name, tc.err, err)
+			continue
+		}
+		if tc.err != nil {
+			continue
+		}
+		if m.Number != tc.number {
+			t.Errorf("unexpected: (%s) %v != %v", tc.name, tc.number, m.Number)
+		}
+		if !bytes.Equal(m.Data, tc.data) {
+			t.Errorf("unexpected: (%s) %v != %v", tc.name, tc.data, m.Data)
+		}
+	}
+}
+
 func TestChannelData_Reset(t *testing.T) {
 	d := &ChannelData{
 		Data:   []byte{1, 2, 3, 4},
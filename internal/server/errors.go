@@ -18,9 +18,12 @@ var (
 	errRequestedTransportMustBeUDP            = errors.New("RequestedTransport must be UDP")
 	errNoDontFragmentSupport                  = errors.New("no support for DONT-FRAGMENT")
 	errRequestWithReservationTokenAndEvenPort = errors.New("Request must not contain RESERVATION-TOKEN and EVEN-PORT")
+	errRequestWithReservationTokenAndFamily   = errors.New("Request must not contain RESERVATION-TOKEN and REQUESTED-ADDRESS-FAMILY")
+	errUnsupportedAddressFamily               = errors.New("requested address family is not supported")
 	errNoAllocationFound                      = errors.New("no allocation found")
 	errNoPermission                           = errors.New("unable to handle send-indication, no permission added")
 	errShortWrite                             = errors.New("packet write smaller than packet")
 	errNoSuchChannelBind                      = errors.New("no such channel bind")
+	errNoSuchReservation                      = errors.New("no such reservation")
 	errFailedWriteSocket                      = errors.New("failed writing to socket")
 )
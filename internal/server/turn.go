@@ -31,6 +31,7 @@ func handleAllocateRequest(r Request, m *stun.Message) error {
 	}
 	requestedPort := 0
 	reservationToken := ""
+	usedReservationToken := ""
 
 	badRequestMsg := buildMsg(m.TransactionID, stun.NewType(stun.MethodAllocate, stun.ClassErrorResponse), &stun.ErrorCodeAttribute{Code: stun.CodeBadRequest})
 	insufficentCapacityMsg := buildMsg(m.TransactionID, stun.NewType(stun.MethodAllocate, stun.ClassErrorResponse), &stun.ErrorCodeAttribute{Code: stun.CodeInsufficientCapacity})
@@ -57,6 +58,24 @@ func handleAllocateRequest(r Request, m *stun.Message) error {
 		return buildAndSendErr(r.Conn, r.SrcAddr, errRequestedTransportMustBeUDP, msg...)
 	}
 
+	// RFC 6156 Section 4.2. If the request contains a REQUESTED-ADDRESS-FAMILY
+	// attribute and a RESERVATION-TOKEN the server rejects the request with a
+	// 400 (Bad Request) error. If the server does not support the address
+	// family requested by the client it rejects the request with a 440
+	// (Address Family not Supported) error. Relayed transport addresses are
+	// only allocated over IPv4.
+	if m.Contains(stun.AttrRequestedAddressFamily) {
+		if m.Contains(stun.AttrReservationToken) {
+			return buildAndSendErr(r.Conn, r.SrcAddr, errRequestWithReservationTokenAndFamily, badRequestMsg...)
+		}
+
+		var requestedFamily proto.RequestedAddressFamily
+		if err = requestedFamily.GetFrom(m); err != nil || requestedFamily != proto.RequestedFamilyIPv4 {
+			msg := buildMsg(m.TransactionID, stun.NewType(stun.MethodAllocate, stun.ClassErrorResponse), &stun.ErrorCodeAttribute{Code: stun.CodeAddrFamilyNotSupported})
+			return buildAndSendErr(r.Conn, r.SrcAddr, errUnsupportedAddressFamily, msg...)
+		}
+	}
+
 	// 4. The request may contain a DONT-FRAGMENT attribute.  If it does,
 	//    but the server does not support sending UDP datagrams with the DF
 	//    bit set to 1 (see Section 12), then the server treats the DONT-
@@ -81,6 +100,16 @@ func handleAllocateRequest(r Request, m *stun.Message) error {
 		if err = evenPort.GetFrom(m); err == nil {
 			return buildAndSendErr(r.Conn, r.SrcAddr, errRequestWithReservationTokenAndEvenPort, badRequestMsg...)
 		}
+
+		reservedPort, ok := r.AllocationManager.GetReservation(string(reservationTokenAttr))
+		if !ok {
+			return buildAndSendErr(r.Conn, r.SrcAddr, errNoSuchReservation, insufficentCapacityMsg...)
+		}
+
+		// The reservation is only consumed once the allocation has been
+		// created, so a failed attempt leaves the token valid.
+		requestedPort = reservedPort
+		usedReservationToken = string(reservationTokenAttr)
 	}
 
 	// 6. The server checks if the request contains an EVEN-PORT attribute.
@@ -97,7 +126,9 @@ func handleAllocateRequest(r Request, m *stun.Message) error {
 			return buildAndSendErr(r.Conn, r.SrcAddr, err, insufficentCapacityMsg...)
 		}
 		requestedPort = randomPort
-		reservationToken = randSeq(8)
+		if evenPort.ReservePort {
+			reservationToken = randSeq(8)
+		}
 	}
 
 	// 7. At any point, the server MAY choose to reject the request with a
@@ -121,6 +152,10 @@ func handleAllocateRequest(r Request, m *stun.Message) error {
 		return buildAndSendErr(r.Conn, r.SrcAddr, err, insufficentCapacityMsg...)
 	}
 
+	if usedReservationToken != "" {
+		r.AllocationManager.DeleteReservation(usedReservationToken)
+	}
+
 	// Once the allocation is created, the server replies with a success
 	// response.  The success response contains:
 	//   * An XOR-RELAYED-ADDRESS attribute containing the relayed transport
@@ -156,8 +191,10 @@ func handleAllocateRequest(r Request, m *stun.Message) error {
 		},
 	}
 
+	// The reservation is for the next-higher port, see
+	// https://tools.ietf.org/html/rfc5766#section-14.6
 	if reservationToken != "" {
-		r.AllocationManager.CreateReservation(reservationToken, relayPort)
+		r.AllocationManager.CreateReservation(reservationToken, relayPort+1)
 		responseAttrs = append(responseAttrs, proto.ReservationToken([]byte(reservationToken)))
 	}
 
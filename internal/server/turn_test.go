@@ -3,6 +3,9 @@
 package server
 
 import (
+	"errors"
+	"fmt"
+	"math"
 	"net"
 	"sync"
 	"testing"
@@ -18,7 +21,7 @@ import (
 func TestAllocationLifeTime(t *testing.T) {
 	t.Run("Parsing", func(t *testing.T) {
 		lifetime := proto.Lifetime{
-			Duration: 5 * time.Second,
+			Duration: 5 * time.Minute,
 		}
 
 		m := &stun.Message{}
@@ -46,8 +49,46 @@ func TestAllocationLifeTime(t *testing.T) {
 		_ = lifetime.AddTo(m2)
 
 		lifetimeDuration := allocationLifeTime(m2)
-		if lifetimeDuration != proto.DefaultLifetime {
-			t.Errorf("Expect lifetimeDuration is %s, but %s", proto.DefaultLifetime, lifetimeDuration)
+		if lifetimeDuration != maximumAllocationLifetime {
+			t.Errorf("Expect lifetimeDuration is %s, but %s", maximumAllocationLifetime, lifetimeDuration)
+		}
+	})
+
+	// If lifetime is smaller than minimumLifetime
+	t.Run("Underflow", func(t *testing.T) {
+		lifetime := proto.Lifetime{
+			Duration: 10 * time.Second,
+		}
+
+		m := &stun.Message{}
+		assert.NoError(t, lifetime.AddTo(m))
+
+		lifetimeDuration := allocationLifeTime(m)
+		if lifetimeDuration != minimumAllocationLifetime {
+			t.Errorf("Expect lifetimeDuration is %s, but %s", minimumAllocationLifetime, lifetimeDuration)
+		}
+	})
+
+	t.Run("Zero", func(t *testing.T) {
+		m := &stun.Message{}
+		assert.NoError(t, (proto.Lifetime{}).AddTo(m))
+
+		if lifetimeDuration := allocationLifeTime(m); lifetimeDuration != 0 {
+			t.Errorf("Expect lifetimeDuration is 0, but %s", lifetimeDuration)
+		}
+	})
+
+	t.Run("MaxUint32", func(t *testing.T) {
+		lifetime := proto.Lifetime{
+			Duration: math.MaxUint32 * time.Second,
+		}
+
+		m := &stun.Message{}
+		assert.NoError(t, lifetime.AddTo(m))
+
+		lifetimeDuration := allocationLifeTime(m)
+		if lifetimeDuration != maximumAllocationLifetime {
+			t.Errorf("Expect lifetimeDuration is %s, but %s", maximumAllocationLifetime, lifetimeDuration)
 		}
 	})
 
@@ -107,3 +148,175 @@ func TestAllocationLifeTime(t *testing.T) {
 		assert.Nil(t, r.AllocationManager.GetAllocation(fiveTuple))
 	})
 }
+
+func TestAllocateReservationToken(t *testing.T) {
+	l, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer func() {
+		assert.NoError(t, l.Close())
+	}()
+
+	logger := logging.NewDefaultLoggerFactory().NewLogger("turn")
+
+	allocationManager, err := allocation.NewManager(allocation.ManagerConfig{
+		AllocatePacketConn: func(network string, requestedPort int) (net.PacketConn, net.Addr, error) {
+			conn, listenErr := net.ListenPacket(network, fmt.Sprintf("127.0.0.1:%d", requestedPort))
+			if listenErr != nil {
+				return nil, nil, listenErr
+			}
+
+			return conn, conn.LocalAddr(), nil
+		},
+		AllocateConn: func(network string, requestedPort int) (net.Conn, net.Addr, error) {
+			return nil, nil, nil
+		},
+		LeveledLogger: logger,
+	})
+	assert.NoError(t, err)
+	defer func() {
+		assert.NoError(t, allocationManager.Close())
+	}()
+
+	staticKey := []byte("ABC")
+	r := Request{
+		AllocationManager: allocationManager,
+		Nonces:            &sync.Map{},
+		Conn:              l,
+		SrcAddr:           &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 5000},
+		Log:               logger,
+		AuthHandler: func(username string, realm string, srcAddr net.Addr) (key []byte, ok bool) {
+			return staticKey, true
+		},
+	}
+	r.Nonces.Store(string(staticKey), time.Now())
+
+	fiveTuple := &allocation.FiveTuple{SrcAddr: r.SrcAddr, DstAddr: r.Conn.LocalAddr(), Protocol: allocation.UDP}
+
+	allocateRequest := func(token string) *stun.Message {
+		m := &stun.Message{}
+		assert.NoError(t, (proto.RequestedTransport{Protocol: proto.ProtoUDP}).AddTo(m))
+		assert.NoError(t, (proto.ReservationToken(token)).AddTo(m))
+		assert.NoError(t, (stun.MessageIntegrity(staticKey)).AddTo(m))
+		assert.NoError(t, (stun.Nonce(staticKey)).AddTo(m))
+		assert.NoError(t, (stun.Realm(staticKey)).AddTo(m))
+		assert.NoError(t, (stun.Username(staticKey)).AddTo(m))
+		return m
+	}
+
+	t.Run("UnknownToken", func(t *testing.T) {
+		err := handleAllocateRequest(r, allocateRequest("unknown1"))
+		assert.True(t, errors.Is(err, errNoSuchReservation), "unexpected error %v", err)
+		assert.Nil(t, allocationManager.GetAllocation(fiveTuple))
+	})
+
+	// find a free port to reserve
+	probe, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	assert.NoError(t, err)
+	reservedPort := probe.LocalAddr().(*net.UDPAddr).Port
+	allocationManager.CreateReservation("token123", reservedPort)
+
+	t.Run("FailedAllocationKeepsToken", func(t *testing.T) {
+		// probe still holds the reserved port so the allocation fails
+		assert.Error(t, handleAllocateRequest(r, allocateRequest("token123")))
+		assert.Nil(t, allocationManager.GetAllocation(fiveTuple))
+
+		port, ok := allocationManager.GetReservation("token123")
+		assert.True(t, ok, "reservation should survive a failed allocation")
+		assert.Equal(t, reservedPort, port)
+	})
+
+	t.Run("AllocationConsumesToken", func(t *testing.T) {
+		assert.NoError(t, probe.Close())
+
+		assert.NoError(t, handleAllocateRequest(r, allocateRequest("token123")))
+
+		a := allocationManager.GetAllocation(fiveTuple)
+		if assert.NotNil(t, a) {
+			assert.Equal(t, reservedPort, a.RelayAddr.(*net.UDPAddr).Port)
+		}
+
+		_, ok := allocationManager.GetReservation("token123")
+		assert.False(t, ok, "reservation should be consumed by the allocation")
+	})
+}
+
+func TestAllocateRequestedAddressFamily(t *testing.T) {
+	l, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer func() {
+		assert.NoError(t, l.Close())
+	}()
+
+	logger := logging.NewDefaultLoggerFactory().NewLogger("turn")
+
+	allocationManager, err := allocation.NewManager(allocation.ManagerConfig{
+		AllocatePacketConn: func(network string, requestedPort int) (net.PacketConn, net.Addr, error) {
+			conn, listenErr := net.ListenPacket(network, "127.0.0.1:0")
+			if listenErr != nil {
+				return nil, nil, listenErr
+			}
+
+			return conn, conn.LocalAddr(), nil
+		},
+		AllocateConn: func(network string, requestedPort int) (net.Conn, net.Addr, error) {
+			return nil, nil, nil
+		},
+		LeveledLogger: logger,
+	})
+	assert.NoError(t, err)
+	defer func() {
+		assert.NoError(t, allocationManager.Close())
+	}()
+
+	staticKey := []byte("ABC")
+	r := Request{
+		AllocationManager: allocationManager,
+		Nonces:            &sync.Map{},
+		Conn:              l,
+		SrcAddr:           &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 5000},
+		Log:               logger,
+		AuthHandler: func(username string, realm string, srcAddr net.Addr) (key []byte, ok bool) {
+			return staticKey, true
+		},
+	}
+	r.Nonces.Store(string(staticKey), time.Now())
+
+	fiveTuple := &allocation.FiveTuple{SrcAddr: r.SrcAddr, DstAddr: r.Conn.LocalAddr(), Protocol: allocation.UDP}
+
+	allocateRequest := func(setters ...stun.Setter) *stun.Message {
+		m := &stun.Message{}
+		assert.NoError(t, (proto.RequestedTransport{Protocol: proto.ProtoUDP}).AddTo(m))
+		for _, s := range setters {
+			assert.NoError(t, s.AddTo(m))
+		}
+		assert.NoError(t, (stun.MessageIntegrity(staticKey)).AddTo(m))
+		assert.NoError(t, (stun.Nonce(staticKey)).AddTo(m))
+		assert.NoError(t, (stun.Realm(staticKey)).AddTo(m))
+		assert.NoError(t, (stun.Username(staticKey)).AddTo(m))
+		return m
+	}
+
+	invalidFamily := stun.RawAttribute{Type: stun.AttrRequestedAddressFamily, Value: []byte{0x03, 0, 0, 0}}
+
+	for _, tc := range []struct {
+		name    string
+		setters []stun.Setter
+		err     error
+	}{
+		{"IPv6", []stun.Setter{proto.RequestedFamilyIPv6}, errUnsupportedAddressFamily},
+		{"InvalidFamily", []stun.Setter{&invalidFamily}, errUnsupportedAddressFamily},
+		{"ReservationToken", []stun.Setter{proto.RequestedFamilyIPv4, proto.ReservationToken("token123")}, errRequestWithReservationTokenAndFamily},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			err := handleAllocateRequest(r, allocateRequest(tc.setters...))
+			assert.True(t, errors.Is(err, tc.err), "unexpected error %v", err)
+			assert.Nil(t, allocationManager.GetAllocation(fiveTuple))
+		})
+	}
+
+	t.Run("IPv4", func(t *testing.T) {
+		assert.NoError(t, handleAllocateRequest(r, allocateRequest(proto.RequestedFamilyIPv4)))
+		assert.NotNil(t, allocationManager.GetAllocation(fiveTuple))
+	})
+}
@@ -14,8 +14,9 @@ import (
 )
 
 const (
-	maximumAllocationLifetime = time.Hour // https://tools.ietf.org/html/rfc5766#section-6.2 defines 3600 seconds recommendation
-	nonceLifetime             = time.Hour // https://tools.ietf.org/html/rfc5766#section-4
+	maximumAllocationLifetime = time.Hour   // https://tools.ietf.org/html/rfc5766#section-6.2 defines 3600 seconds recommendation
+	minimumAllocationLifetime = time.Minute // don't create allocations that expire almost immediately
+	nonceLifetime             = time.Hour   // https://tools.ietf.org/html/rfc5766#section-4
 
 )
 
@@ -124,8 +125,13 @@ func allocationLifeTime(m *stun.Message) time.Duration {
 
 	var lifetime proto.Lifetime
 	if err := lifetime.GetFrom(m); err == nil {
-		if lifetime.Duration < maximumAllocationLifetime {
-			lifetimeDuration = lifetime.Duration
+		lifetimeDuration = lifetime.Duration
+		switch {
+		case lifetimeDuration > maximumAllocationLifetime:
+			lifetimeDuration = maximumAllocationLifetime
+		case lifetimeDuration != 0 && lifetimeDuration < minimumAllocationLifetime:
+			// A lifetime of 0 deletes the allocation on Refresh
+			lifetimeDuration = minimumAllocationLifetime
 		}
 	}
 
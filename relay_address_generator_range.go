@@ -66,7 +66,12 @@ func (r *RelayAddressGeneratorPortRange) AllocatePacketConn(network string, requ
 		if err != nil {
 			return nil, nil, err
 		}
-		relayAddr := conn.LocalAddr().(*net.UDPAddr)
+		relayAddr, ok := conn.LocalAddr().(*net.UDPAddr)
+		if !ok {
+			_ = conn.Close()
+			return nil, nil, errFailedToCastUDPAddr
+		}
+
 		relayAddr.IP = r.RelayAddress
 		return conn, relayAddr, nil
 	}
@@ -78,7 +83,12 @@ func (r *RelayAddressGeneratorPortRange) AllocatePacketConn(network string, requ
 			continue
 		}
 
-		relayAddr := conn.LocalAddr().(*net.UDPAddr)
+		relayAddr, ok := conn.LocalAddr().(*net.UDPAddr)
+		if !ok {
+			_ = conn.Close()
+			return nil, nil, errFailedToCastUDPAddr
+		}
+
 		relayAddr.IP = r.RelayAddress
 		return conn, relayAddr, nil
 	}
@@ -43,7 +43,12 @@ func (r *RelayAddressGeneratorStatic) AllocatePacketConn(network string, request
 	}
 
 	// Replace actual listening IP with the user requested one of RelayAddressGeneratorStatic
-	relayAddr := conn.LocalAddr().(*net.UDPAddr)
+	relayAddr, ok := conn.LocalAddr().(*net.UDPAddr)
+	if !ok {
+		_ = conn.Close()
+		return nil, nil, errFailedToCastUDPAddr
+	}
+
 	relayAddr.IP = r.RelayAddress
 
 	return conn, relayAddr, nil
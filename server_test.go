@@ -4,6 +4,7 @@ package turn
 
 import (
 	"net"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -319,3 +320,82 @@ func TestServerVNet(t *testing.T) {
 		assert.NoError(t, v.Close(), "should succeed")
 	})
 }
+
+func TestRelayUnderPacketLoss(t *testing.T) {
+	lim := test.TimeOut(time.Second * 30)
+	defer lim.Stop()
+
+	report := test.CheckRoutines(t)
+	defer report()
+
+	loggerFactory := logging.NewDefaultLoggerFactory()
+
+	v, err := buildVNet()
+	assert.NoError(t, err)
+
+	// drop 5% of the chunks crossing the WAN, including the client's
+	// Allocate and CreatePermission transactions
+	var chunks uint64
+	v.wan.AddChunkFilter(func(c vnet.Chunk) bool {
+		return atomic.AddUint64(&chunks, 1)%20 != 0
+	})
+
+	lconn, err := v.netL0.ListenPacket("udp4", "0.0.0.0:0")
+	assert.NoError(t, err)
+
+	client, err := NewClient(&ClientConfig{
+		STUNServerAddr: "stun.pion.ly:3478",
+		TURNServerAddr: "turn.pion.ly:3478",
+		Username:       "user",
+		Password:       "pass",
+		Conn:           lconn,
+		Net:            v.netL0,
+		LoggerFactory:  loggerFactory,
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, client.Listen())
+
+	conn, err := client.Allocate()
+	assert.NoError(t, err)
+
+	echoConn, err := v.net1.ListenPacket("udp4", "1.2.3.5:5678")
+	assert.NoError(t, err)
+
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, from, err2 := echoConn.ReadFrom(buf)
+			if err2 != nil {
+				break
+			}
+
+			if _, err2 = echoConn.WriteTo(buf[:n], from); err2 != nil {
+				break
+			}
+		}
+	}()
+
+	const packets = 100
+	received := 0
+	buf := make([]byte, 1500)
+	for i := 0; i < packets; i++ {
+		_, err = conn.WriteTo([]byte("Hello"), echoConn.LocalAddr())
+		assert.NoError(t, err)
+
+		assert.NoError(t, conn.SetReadDeadline(time.Now().Add(200*time.Millisecond)))
+		if _, _, err2 := conn.ReadFrom(buf); err2 == nil {
+			received++
+		}
+	}
+
+	// each echo crosses the WAN four times, so roughly 81% should come back
+	t.Logf("received %d of %d echoes", received, packets)
+	assert.GreaterOrEqual(t, received, packets*7/10, "relay should keep working under packet loss")
+	assert.Less(t, received, packets, "packet loss should have been applied")
+
+	client.Close()
+	assert.NoError(t, conn.Close())
+	assert.NoError(t, echoConn.Close())
+	assert.NoError(t, lconn.Close())
+	assert.NoError(t, v.Close())
+}